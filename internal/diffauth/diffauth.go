@@ -0,0 +1,205 @@
+// Package diffauth clasifica respuestas por similitud de forma en vez de
+// por coincidencia de strings. classifyResponse falla contra APIs que
+// devuelven HTTP 200 con "data": null, filtran campos en silencio, o
+// responden con un INTERNAL_SERVER_ERROR genérico tanto para fallos de
+// autorización como para cualquier otro error; ese tipo de API no dice
+// "forbidden" en ningún sitio que un regex pueda encontrar. En su lugar,
+// diffauth registra durante la fase autenticada cómo luce una respuesta
+// exitosa y cómo luce un rechazo "bien formado" (payload con tipos
+// inválidos, que cualquier servidor rechaza sin que medie autorización), y
+// clasifica respuestas nuevas por a cuál de esas dos firmas se parecen más.
+package diffauth
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ScarlyCodex/gqlms/internal/report"
+)
+
+// tokenRegexp separa el mensaje de error en palabras para compararlas como
+// conjunto en vez de como texto exacto.
+var tokenRegexp = regexp.MustCompile(`[a-zA-Z]+`)
+
+// gqlBody es el subconjunto de una respuesta GraphQL que importa para
+// construir una firma; diffauth mantiene su propia copia en vez de
+// importar la del paquete main para no introducir un ciclo.
+type gqlBody struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// Signature es el vector de características de una respuesta: lo bastante
+// pequeño para compararlo por similitud de Jaccard, lo bastante específico
+// para distinguir un 200 con datos reales de un 200 con data:null.
+type Signature struct {
+	StatusCode    int
+	ErrorCodes    []string
+	DataNull      bool
+	FieldNames    []string
+	MessageTokens []string
+}
+
+// ExtractSignature construye la firma de una respuesta a partir de su
+// status HTTP y su cuerpo (ya leído, para no consumir el body del caller).
+func ExtractSignature(statusCode int, body []byte) Signature {
+	sig := Signature{StatusCode: statusCode}
+
+	var parsed gqlBody
+	if json.Unmarshal(body, &parsed) != nil {
+		return sig
+	}
+
+	codes := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, errObj := range parsed.Errors {
+		if errObj.Extensions.Code != "" {
+			codes[strings.ToUpper(errObj.Extensions.Code)] = true
+		}
+		for _, tok := range tokenRegexp.FindAllString(strings.ToLower(errObj.Message), -1) {
+			tokens[tok] = true
+		}
+	}
+	sig.ErrorCodes = sortedKeys(codes)
+	sig.MessageTokens = sortedKeys(tokens)
+
+	isNull := len(parsed.Data) == 0 || string(parsed.Data) == "null"
+	sig.DataNull = len(parsed.Errors) > 0 && isNull
+
+	if !isNull {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(parsed.Data, &fields) == nil {
+			names := map[string]bool{}
+			for name := range fields {
+				names[name] = true
+			}
+			sig.FieldNames = sortedKeys(names)
+		}
+	}
+
+	return sig
+}
+
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tokenSet aplana la firma a un conjunto de tokens etiquetados por
+// característica, para que Jaccard compare "todo el vector" de una vez en
+// vez de promediar similitudes campo a campo.
+func (s Signature) tokenSet() map[string]bool {
+	set := map[string]bool{
+		"status:" + strconv.Itoa(s.StatusCode):       true,
+		"dataNull:" + strconv.FormatBool(s.DataNull): true,
+	}
+	for _, code := range s.ErrorCodes {
+		set["code:"+code] = true
+	}
+	for _, field := range s.FieldNames {
+		set["field:"+field] = true
+	}
+	for _, tok := range s.MessageTokens {
+		set["tok:"+tok] = true
+	}
+	return set
+}
+
+// Jaccard mide qué tan parecidas son dos firmas como |intersección|/|unión|
+// de sus conjuntos de tokens. Dos respuestas idénticas dan 1; dos sin
+// ningún token en común dan 0.
+func Jaccard(a, b Signature) float64 {
+	setA, setB := a.tokenSet(), b.tokenSet()
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+
+	intersection := 0
+	union := map[string]bool{}
+	for k := range setA {
+		union[k] = true
+	}
+	for k := range setB {
+		union[k] = true
+		if setA[k] {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(union))
+}
+
+// Baseline son las dos firmas de referencia de una operación: cómo luce
+// cuando se permite (con input válido) y cómo luce un rechazo bien formado
+// (con input inválido, que no tiene nada que ver con autorización).
+type Baseline struct {
+	Success Signature
+	Invalid Signature
+}
+
+// Classifier compara firmas nuevas contra los baselines grabados por
+// operación y decide si se parecen más a "permitido" o a "denegado".
+type Classifier struct {
+	threshold float64
+	baselines map[string]Baseline
+}
+
+// NewClassifier crea un Classifier vacío. threshold es la similitud mínima
+// de Jaccard para que una firma se considere suficientemente parecida a un
+// baseline como para decidir en base a él; por debajo de threshold para
+// ambos baselines, el resultado es "inconclusive".
+func NewClassifier(threshold float64) *Classifier {
+	return &Classifier{threshold: threshold, baselines: make(map[string]Baseline)}
+}
+
+// baselineKey identifica una operación por kind+name: queryType y
+// mutationType pueden legalmente exponer un campo con el mismo nombre (p.
+// ej. Query.export y Mutation.export), y cada uno necesita su propio
+// baseline.
+func baselineKey(kind, operation string) string {
+	return kind + "/" + operation
+}
+
+// Record guarda el baseline de una operación.
+func (c *Classifier) Record(kind, operation string, success, invalid Signature) {
+	c.baselines[baselineKey(kind, operation)] = Baseline{Success: success, Invalid: invalid}
+}
+
+// Classify compara sig contra los baselines de operation y devuelve la
+// clasificación (report.Allowed/Denied/Inconclusive) junto con las
+// similitudes calculadas, para que quien llame pueda registrarlas en el
+// reporte. Si no hay baseline para la operación (p. ej. el baseline falló
+// al grabarse), el resultado es siempre "inconclusive".
+func (c *Classifier) Classify(kind, operation string, sig Signature) (classification string, successSim, invalidSim float64) {
+	baseline, ok := c.baselines[baselineKey(kind, operation)]
+	if !ok {
+		return report.Inconclusive, 0, 0
+	}
+
+	successSim = Jaccard(sig, baseline.Success)
+	invalidSim = Jaccard(sig, baseline.Invalid)
+
+	switch {
+	case successSim < c.threshold && invalidSim < c.threshold:
+		return report.Inconclusive, successSim, invalidSim
+	case invalidSim > successSim:
+		return report.Denied, successSim, invalidSim
+	default:
+		return report.Allowed, successSim, invalidSim
+	}
+}
@@ -0,0 +1,59 @@
+package diffauth
+
+import (
+	"testing"
+
+	"github.com/ScarlyCodex/gqlms/internal/report"
+)
+
+func TestJaccardIdenticalAndDisjoint(t *testing.T) {
+	sig := ExtractSignature(200, []byte(`{"data":{"user":{"id":"1"}}}`))
+	if got := Jaccard(sig, sig); got != 1 {
+		t.Errorf("Jaccard(sig, sig) = %v, want 1", got)
+	}
+
+	other := ExtractSignature(403, []byte(`{"data":null,"errors":[{"message":"forbidden","extensions":{"code":"FORBIDDEN"}}]}`))
+	if got := Jaccard(sig, other); got >= 1 {
+		t.Errorf("Jaccard(sig, other) = %v, want less than 1 for distinct signatures", got)
+	}
+}
+
+func TestClassifyWithoutBaselineIsInconclusive(t *testing.T) {
+	c := NewClassifier(0.6)
+	sig := ExtractSignature(200, []byte(`{"data":{"user":{"id":"1"}}}`))
+
+	classification, successSim, invalidSim := c.Classify("query", "user", sig)
+	if classification != report.Inconclusive {
+		t.Errorf("Classify with no recorded baseline = %q, want %q", classification, report.Inconclusive)
+	}
+	if successSim != 0 || invalidSim != 0 {
+		t.Errorf("Classify with no recorded baseline returned similarities %v/%v, want 0/0", successSim, invalidSim)
+	}
+}
+
+func TestClassifyMatchesRecordedBaseline(t *testing.T) {
+	c := NewClassifier(0.6)
+	success := ExtractSignature(200, []byte(`{"data":{"user":{"id":"1"}}}`))
+	invalid := ExtractSignature(400, []byte(`{"data":null,"errors":[{"message":"invalid input","extensions":{"code":"BAD_USER_INPUT"}}]}`))
+	c.Record("query", "user", success, invalid)
+
+	if classification, _, _ := c.Classify("query", "user", success); classification != report.Allowed {
+		t.Errorf("Classify(success-shaped response) = %q, want %q", classification, report.Allowed)
+	}
+	if classification, _, _ := c.Classify("query", "user", invalid); classification != report.Denied {
+		t.Errorf("Classify(invalid-shaped response) = %q, want %q", classification, report.Denied)
+	}
+}
+
+func TestClassifyKeysByKindSoSameNameFieldsDontCollide(t *testing.T) {
+	c := NewClassifier(0.6)
+	querySuccess := ExtractSignature(200, []byte(`{"data":{"export":{"id":"1"}}}`))
+	queryInvalid := ExtractSignature(400, []byte(`{"data":null,"errors":[{"message":"invalid input"}]}`))
+	c.Record("query", "export", querySuccess, queryInvalid)
+
+	// Mutation.export never had a baseline recorded; it must stay
+	// inconclusive instead of silently reusing Query.export's baseline.
+	if classification, _, _ := c.Classify("mutation", "export", querySuccess); classification != report.Inconclusive {
+		t.Errorf("Classify(mutation/export) = %q, want %q since no baseline was recorded for that kind", classification, report.Inconclusive)
+	}
+}
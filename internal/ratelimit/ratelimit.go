@@ -0,0 +1,123 @@
+// Package ratelimit envuelve golang.org/x/time/rate con la política
+// adaptativa que necesita testOperations: arrancar al RPS configurado,
+// reducirlo a la mitad en cuanto el servidor devuelve una señal de
+// rate-limit, y recuperarlo gradualmente tras una racha de éxitos.
+package ratelimit
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// restoreStreak es cuántos éxitos seguidos hacen falta antes de subir el
+// límite un escalón hacia el RPS base.
+const restoreStreak = 20
+
+// restoreFactor es el factor de recuperación aplicado cada restoreStreak
+// éxitos consecutivos.
+const restoreFactor = 1.2
+
+// floorFraction acota cuánto puede bajar el límite respecto al RPS base,
+// para que un servidor agresivo no lo deje en 0.
+const floorFraction = 0.125
+
+// AdaptiveLimiter controla el ritmo global de peticiones y reacciona a las
+// señales de rate-limit del servidor sin que cada goroutine tenga que
+// coordinar nada más allá de llamar a Wait/OnRateLimited/OnSuccess.
+type AdaptiveLimiter struct {
+	mu            sync.Mutex
+	limiter       *rate.Limiter
+	base          rate.Limit
+	floor         rate.Limit
+	successStreak int
+}
+
+// New crea un AdaptiveLimiter arrancando en rps peticiones por segundo. Un
+// rps <= 0 se trata como "sin límite configurado" y usa un valor por
+// defecto conservador.
+func New(rps float64) *AdaptiveLimiter {
+	if rps <= 0 {
+		rps = 5
+	}
+	limit := rate.Limit(rps)
+	burst := int(rps)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &AdaptiveLimiter{
+		limiter: rate.NewLimiter(limit, burst),
+		base:    limit,
+		floor:   limit * floorFraction,
+	}
+}
+
+// Wait bloquea hasta que el limiter conceda un token.
+func (a *AdaptiveLimiter) Wait(ctx context.Context) error {
+	return a.limiter.Wait(ctx)
+}
+
+// OnRateLimited parte el límite actual en dos (sin bajar del floor) y
+// reinicia la racha de éxitos.
+func (a *AdaptiveLimiter) OnRateLimited() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.successStreak = 0
+	newLimit := a.limiter.Limit() / 2
+	if newLimit < a.floor {
+		newLimit = a.floor
+	}
+	a.setLimit(newLimit)
+}
+
+// OnSuccess cuenta un éxito y, tras restoreStreak seguidos, recupera el
+// límite un escalón hacia el RPS base.
+func (a *AdaptiveLimiter) OnSuccess() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.limiter.Limit() >= a.base {
+		a.successStreak = 0
+		return
+	}
+
+	a.successStreak++
+	if a.successStreak < restoreStreak {
+		return
+	}
+	a.successStreak = 0
+
+	newLimit := a.limiter.Limit() * restoreFactor
+	if newLimit > a.base {
+		newLimit = a.base
+	}
+	a.setLimit(newLimit)
+}
+
+func (a *AdaptiveLimiter) setLimit(limit rate.Limit) {
+	a.limiter.SetLimit(limit)
+	burst := int(limit)
+	if burst < 1 {
+		burst = 1
+	}
+	a.limiter.SetBurst(burst)
+}
+
+// Backoff devuelve una espera con crecimiento exponencial y jitter para el
+// intento dado (0-indexado), usada entre reintentos tras un rate-limit.
+func Backoff(attempt int) time.Duration {
+	base := 250 * time.Millisecond
+	max := 10 * time.Second
+
+	wait := base << attempt
+	if wait > max || wait <= 0 {
+		wait = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(wait) / 2))
+	return wait/2 + jitter
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffGrowsWithAttemptAndStaysCapped(t *testing.T) {
+	if got := Backoff(0); got <= 0 || got > 10*time.Second {
+		t.Errorf("Backoff(0) = %v, want a positive duration capped at 10s", got)
+	}
+	if got := Backoff(10); got <= 0 || got > 10*time.Second {
+		t.Errorf("Backoff(10) = %v, want the cap to hold even for a high attempt count", got)
+	}
+}
+
+func TestAdaptiveLimiterHalvesOnRateLimitAndFloors(t *testing.T) {
+	a := New(8)
+
+	for i := 0; i < 10; i++ {
+		a.OnRateLimited()
+	}
+
+	if got := a.limiter.Limit(); got < a.floor {
+		t.Errorf("limit = %v dropped below floor %v after repeated rate-limit signals", got, a.floor)
+	}
+}
+
+func TestAdaptiveLimiterRestoresAfterSuccessStreak(t *testing.T) {
+	a := New(8)
+	a.OnRateLimited()
+	halved := a.limiter.Limit()
+
+	for i := 0; i < restoreStreak-1; i++ {
+		a.OnSuccess()
+	}
+	if got := a.limiter.Limit(); got != halved {
+		t.Fatalf("limit = %v changed before restoreStreak successes were reached", got)
+	}
+
+	a.OnSuccess()
+	if got := a.limiter.Limit(); got <= halved {
+		t.Errorf("limit = %v, want it to rise above %v after a full restoreStreak of successes", got, halved)
+	}
+}
+
+func TestNewFloorsNonPositiveRPS(t *testing.T) {
+	a := New(0)
+	if a.base <= 0 {
+		t.Errorf("New(0).base = %v, want a positive default RPS", a.base)
+	}
+}
@@ -0,0 +1,186 @@
+// Package report serializa los resultados de una corrida de gqlms a
+// formatos consumibles por una pipeline: JSON para procesar a mano, SARIF
+// para que GitHub code-scanning y GitLab security dashboards los entiendan
+// sin intermediarios, y Markdown para revisarlos en una PR.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Clasificaciones posibles de una operación probada. "allowed"/"denied" son
+// los dos resultados claros; "error" cubre fallos de transporte o 5xx que
+// no dicen nada sobre autorización, e "inconclusive" cubre respuestas (p.
+// ej. 400) demasiado ambiguas para clasificar con confianza.
+const (
+	Allowed      = "allowed"
+	Denied       = "denied"
+	ErrorStatus  = "error"
+	Inconclusive = "inconclusive"
+)
+
+// Result es el resultado de probar una operación, con suficiente detalle
+// para que una pipeline no tenga que volver a ejecutar la petición para
+// entender por qué se clasificó como se clasificó.
+type Result struct {
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`
+	ArgTypes       map[string]string `json:"argTypes,omitempty"`
+	Payload        string            `json:"payload,omitempty"`
+	HTTPStatus     int               `json:"httpStatus,omitempty"`
+	ErrorCodes     []string          `json:"errorCodes,omitempty"`
+	ErrorMessages  []string          `json:"errorMessages,omitempty"`
+	Classification string            `json:"classification"`
+	Heuristic      string            `json:"heuristic,omitempty"`
+}
+
+// Write serializa results al path indicado en el formato pedido (json,
+// sarif o md). defaultPath se usa cuando path viene vacío.
+func Write(results []Result, format, path, defaultPath string) error {
+	if path == "" {
+		path = defaultPath
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case "json":
+		data, err = writeJSON(results)
+	case "sarif":
+		data, err = writeSARIF(results)
+	case "md":
+		data = []byte(writeMarkdown(results))
+	default:
+		return fmt.Errorf("❌ unknown -output value %q (want json, sarif or md)", format)
+	}
+	if err != nil {
+		return fmt.Errorf("❌ error building %s report: %w", format, err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("❌ error writing %s: %w", path, err)
+	}
+	fmt.Printf("📄 Wrote %s report to %s\n", format, path)
+	return nil
+}
+
+func writeJSON(results []Result) ([]byte, error) {
+	return json.MarshalIndent(results, "", "  ")
+}
+
+// sarifRuleID es el identificador de la única regla que gqlms reporta hoy:
+// una operación permitida cuando la petición se hizo sin credenciales
+// válidas, es decir, una autorización rota.
+const sarifRuleID = "gqlms/broken-authorization"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string    `json:"id"`
+	ShortDescription sarifText `json:"shortDescription"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// writeSARIF mapea cada operación permitida a un result de la regla
+// gqlms/broken-authorization. Las operaciones no permitidas no aparecen:
+// SARIF es para hallazgos, no para un reporte completo de la corrida (ese
+// es el propósito del formato json).
+func writeSARIF(results []Result) ([]byte, error) {
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name: "gqlms",
+				Rules: []sarifRule{{
+					ID:               sarifRuleID,
+					ShortDescription: sarifText{Text: "GraphQL operation allowed without proper authorization"},
+				}},
+			}},
+		}},
+	}
+
+	for _, result := range results {
+		if result.Classification != Allowed {
+			continue
+		}
+		log.Runs[0].Results = append(log.Runs[0].Results, sarifResult{
+			RuleID: sarifRuleID,
+			Level:  "error",
+			Message: sarifText{Text: fmt.Sprintf(
+				"%s %q returned HTTP %d with no denial signal; it may be reachable without authorization",
+				result.Kind, result.Name, result.HTTPStatus,
+			)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: fmt.Sprintf("graphql://%s/%s", result.Kind, result.Name)},
+			}}},
+		})
+	}
+
+	return json.MarshalIndent(log, "", "  ")
+}
+
+// writeMarkdown produce una tabla legible en PR/CI con la clasificación y
+// la heurística que la disparó, para revisar un hallazgo sin tener que
+// abrir el json.
+func writeMarkdown(results []Result) string {
+	var md strings.Builder
+	md.WriteString("# gqlms authorization report\n\n")
+	md.WriteString("| Operation | Kind | Status | Classification | Heuristic |\n")
+	md.WriteString("|---|---|---|---|---|\n")
+	for _, result := range results {
+		md.WriteString(fmt.Sprintf("| %s | %s | %d | %s | %s |\n",
+			result.Name, result.Kind, result.HTTPStatus, result.Classification, orDash(result.Heuristic)))
+	}
+	return md.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+// Package roles carga identidades nombradas (admin, user, guest, anon...)
+// desde un fichero YAML y las superpone sobre los headers base de la
+// petición, para que gqlms pueda probar cada operación una vez por rol en
+// lugar de un único flip autenticado/no-autenticado.
+package roles
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Role es una identidad con nombre, su nivel relativo de privilegio (usado
+// para priorizar candidatos BOLA/BFLA: el nivel más alto se asume el rol
+// "correcto" contra el que comparar) y los headers que la distinguen de la
+// petición base.
+type Role struct {
+	Name    string            `yaml:"name"`
+	Level   int               `yaml:"level"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type config struct {
+	Roles []Role `yaml:"roles"`
+}
+
+// Load parsea un fichero roles.yaml con la forma:
+//
+//	roles:
+//	  - name: admin
+//	    level: 3
+//	    headers:
+//	      Authorization: "Bearer admin-token"
+//	  - name: guest
+//	    level: 0
+//	    headers: {}
+func Load(path string) ([]Role, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("❌ error reading roles file: %w", err)
+	}
+
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("❌ invalid roles file: %w", err)
+	}
+	if len(cfg.Roles) == 0 {
+		return nil, fmt.Errorf("❌ roles file %q defines no roles", path)
+	}
+
+	return cfg.Roles, nil
+}
+
+// Overlay superpone los headers del rol sobre los headers base de la
+// petición: un valor vacío borra el header (equivalente al viejo modelo de
+// -unauth), cualquier otro valor lo añade o lo sustituye.
+func (r Role) Overlay(base map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(r.Headers))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range r.Headers {
+		if v == "" {
+			delete(merged, k)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// MaxLevel devuelve el nivel de privilegio más alto entre roles.
+func MaxLevel(roles []Role) int {
+	max := 0
+	for i, r := range roles {
+		if i == 0 || r.Level > max {
+			max = r.Level
+		}
+	}
+	return max
+}
@@ -0,0 +1,49 @@
+package roles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOverlayAddsReplacesAndDeletesHeaders(t *testing.T) {
+	base := map[string]string{
+		"Authorization": "Bearer base-token",
+		"X-Request-Id":  "abc",
+	}
+	role := Role{
+		Name: "guest",
+		Headers: map[string]string{
+			"Authorization": "",
+			"X-Role":        "guest",
+		},
+	}
+
+	got := role.Overlay(base)
+	want := map[string]string{
+		"X-Request-Id": "abc",
+		"X-Role":       "guest",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Overlay() = %v, want %v", got, want)
+	}
+
+	// base must be untouched by the overlay.
+	if _, ok := base["X-Role"]; ok {
+		t.Error("Overlay() mutated the base headers map")
+	}
+}
+
+func TestMaxLevel(t *testing.T) {
+	if got := MaxLevel(nil); got != 0 {
+		t.Errorf("MaxLevel(nil) = %d, want 0", got)
+	}
+
+	roles := []Role{
+		{Name: "guest", Level: 0},
+		{Name: "admin", Level: 3},
+		{Name: "user", Level: 1},
+	}
+	if got := MaxLevel(roles); got != 3 {
+		t.Errorf("MaxLevel(roles) = %d, want 3", got)
+	}
+}
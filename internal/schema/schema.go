@@ -0,0 +1,432 @@
+// Package schema realiza la introspección completa de un endpoint GraphQL
+// una única vez, cachea el resultado en disco y expone lookups sobre los
+// tipos descubiertos (inputs, enums, uniones) para que el resto de gqlms
+// pueda construir payloads válidos sin volver a golpear la red.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// TypeRef es la referencia recursiva que GraphQL usa para describir tipos
+// (incluyendo los envoltorios NON_NULL y LIST).
+type TypeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *TypeRef `json:"ofType,omitempty"`
+}
+
+// Arg representa un argumento de un campo (mutación, query o suscripción).
+type Arg struct {
+	Name string  `json:"name"`
+	Type TypeRef `json:"type"`
+}
+
+// Field representa un campo de mutationType/queryType/subscriptionType,
+// o un inputField de un INPUT_OBJECT.
+type Field struct {
+	Name string  `json:"name"`
+	Args []Arg   `json:"args"`
+	Type TypeRef `json:"type"`
+}
+
+// EnumValue representa un valor posible de un tipo ENUM.
+type EnumValue struct {
+	Name string `json:"name"`
+}
+
+// NamedRef se usa para interfaces/possibleTypes, que sólo exponen el nombre.
+type NamedRef struct {
+	Name string `json:"name"`
+}
+
+// FullType es la descripción completa de un tipo tal y como la entrega la
+// query de introspección estándar.
+type FullType struct {
+	Kind          string      `json:"kind"`
+	Name          string      `json:"name"`
+	Fields        []Field     `json:"fields"`
+	InputFields   []Field     `json:"inputFields"`
+	EnumValues    []EnumValue `json:"enumValues"`
+	Interfaces    []NamedRef  `json:"interfaces"`
+	PossibleTypes []NamedRef  `json:"possibleTypes"`
+}
+
+// introspectionQuery es la query de introspección completa (types, fields,
+// inputFields, enumValues, interfaces, possibleTypes) resuelta una sola vez
+// por ejecución.
+const introspectionQuery = `
+query IntrospectSchema {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types {
+      kind
+      name
+      fields(includeDeprecated: true) {
+        name
+        args { name type { ` + typeRefFragment + ` } }
+        type { ` + typeRefFragment + ` }
+      }
+      inputFields {
+        name
+        type { ` + typeRefFragment + ` }
+      }
+      enumValues(includeDeprecated: true) { name }
+      interfaces { name }
+      possibleTypes { name }
+    }
+  }
+}`
+
+// typeRefFragment camina cuatro niveles de ofType, suficiente para cubrir
+// los envoltorios NON_NULL(LIST(NON_NULL(...))) que se ven en la práctica.
+const typeRefFragment = `
+kind
+name
+ofType {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+      }
+    }
+  }
+}`
+
+type introspectionResponse struct {
+	Data struct {
+		Schema struct {
+			QueryType        *NamedRef  `json:"queryType"`
+			MutationType     *NamedRef  `json:"mutationType"`
+			SubscriptionType *NamedRef  `json:"subscriptionType"`
+			Types            []FullType `json:"types"`
+		} `json:"__schema"`
+	} `json:"data"`
+}
+
+// cachedSchema es el formato que se persiste en --schema-file.
+type cachedSchema struct {
+	QueryType        string     `json:"queryType"`
+	MutationType     string     `json:"mutationType"`
+	SubscriptionType string     `json:"subscriptionType"`
+	Types            []FullType `json:"types"`
+}
+
+// SchemaClient mantiene el schema introspectado de un endpoint y resuelve
+// lookups sobre él sin volver a consultar la red.
+type SchemaClient struct {
+	Endpoint string
+	Headers  map[string]string
+	Proxy    string
+	UseProxy bool
+
+	queryTypeName        string
+	mutationTypeName     string
+	subscriptionTypeName string
+	types                map[string]*FullType
+}
+
+// NewSchemaClient crea un cliente listo para cargar el schema vía Load.
+func NewSchemaClient(endpoint string, headers map[string]string, proxy string, useProxy bool) *SchemaClient {
+	return &SchemaClient{
+		Endpoint: endpoint,
+		Headers:  headers,
+		Proxy:    proxy,
+		UseProxy: useProxy,
+		types:    make(map[string]*FullType),
+	}
+}
+
+// Load rellena el cliente a partir de schemaFile si existe, o ejecuta la
+// introspección completa contra el endpoint y la guarda en schemaFile para
+// reruns posteriores con --schema-file. Si schemaFile está vacío, siempre
+// introspecciona y no persiste nada.
+func (c *SchemaClient) Load(schemaFile string) error {
+	if schemaFile != "" {
+		if data, err := os.ReadFile(schemaFile); err == nil {
+			return c.loadFromCache(data)
+		}
+	}
+
+	if err := c.fetchFromNetwork(); err != nil {
+		return err
+	}
+
+	if schemaFile != "" {
+		if err := c.saveToCache(schemaFile); err != nil {
+			return fmt.Errorf("⚠️ schema fetched but could not be cached: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *SchemaClient) loadFromCache(data []byte) error {
+	var cached cachedSchema
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return fmt.Errorf("❌ invalid schema cache: %w", err)
+	}
+
+	c.queryTypeName = cached.QueryType
+	c.mutationTypeName = cached.MutationType
+	c.subscriptionTypeName = cached.SubscriptionType
+	c.indexTypes(cached.Types)
+	return nil
+}
+
+func (c *SchemaClient) saveToCache(schemaFile string) error {
+	cached := cachedSchema{
+		QueryType:        c.queryTypeName,
+		MutationType:     c.mutationTypeName,
+		SubscriptionType: c.subscriptionTypeName,
+	}
+	for _, t := range c.types {
+		cached.Types = append(cached.Types, *t)
+	}
+
+	data, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(schemaFile, data, 0o644)
+}
+
+func (c *SchemaClient) fetchFromNetwork() error {
+	payload, _ := json.Marshal(map[string]string{"query": introspectionQuery})
+
+	resp, err := c.sendRequest(payload)
+	if err != nil {
+		return fmt.Errorf("❌ error fetching schema: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var introspected introspectionResponse
+	if err := json.Unmarshal(body, &introspected); err != nil {
+		return fmt.Errorf("❌ invalid introspection response: %w", err)
+	}
+
+	if introspected.Data.Schema.QueryType != nil {
+		c.queryTypeName = introspected.Data.Schema.QueryType.Name
+	}
+	if introspected.Data.Schema.MutationType != nil {
+		c.mutationTypeName = introspected.Data.Schema.MutationType.Name
+	}
+	if introspected.Data.Schema.SubscriptionType != nil {
+		c.subscriptionTypeName = introspected.Data.Schema.SubscriptionType.Name
+	}
+	c.indexTypes(introspected.Data.Schema.Types)
+	return nil
+}
+
+func (c *SchemaClient) indexTypes(types []FullType) {
+	c.types = make(map[string]*FullType, len(types))
+	for i := range types {
+		t := types[i]
+		c.types[t.Name] = &t
+	}
+}
+
+func (c *SchemaClient) sendRequest(payload []byte) (*http.Response, error) {
+	transport := &http.Transport{}
+	if c.UseProxy {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("❌ invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest("POST", c.Endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+
+	return client.Do(req)
+}
+
+// Mutations devuelve los campos de mutationType.
+func (c *SchemaClient) Mutations() []Field {
+	return c.fieldsOf(c.mutationTypeName)
+}
+
+// Queries devuelve los campos de queryType.
+func (c *SchemaClient) Queries() []Field {
+	return c.fieldsOf(c.queryTypeName)
+}
+
+// Subscriptions devuelve los campos de subscriptionType.
+func (c *SchemaClient) Subscriptions() []Field {
+	return c.fieldsOf(c.subscriptionTypeName)
+}
+
+func (c *SchemaClient) fieldsOf(typeName string) []Field {
+	if typeName == "" {
+		return nil
+	}
+	t, ok := c.types[typeName]
+	if !ok {
+		return nil
+	}
+	return t.Fields
+}
+
+// ResolveType devuelve el FullType cacheado para un nombre de tipo, o nil
+// si el schema no lo conoce.
+func (c *SchemaClient) ResolveType(name string) *FullType {
+	return c.types[name]
+}
+
+// InputFields devuelve los inputFields de un INPUT_OBJECT por nombre.
+func (c *SchemaClient) InputFields(name string) []Field {
+	t := c.ResolveType(name)
+	if t == nil {
+		return nil
+	}
+	return t.InputFields
+}
+
+// EnumValues devuelve los nombres de los valores posibles de un ENUM.
+func (c *SchemaClient) EnumValues(name string) []string {
+	t := c.ResolveType(name)
+	if t == nil {
+		return nil
+	}
+	values := make([]string, 0, len(t.EnumValues))
+	for _, v := range t.EnumValues {
+		values = append(values, v.Name)
+	}
+	return values
+}
+
+// UnionMembers devuelve los nombres de los possibleTypes de una UNION o
+// INTERFACE.
+func (c *SchemaClient) UnionMembers(name string) []string {
+	t := c.ResolveType(name)
+	if t == nil {
+		return nil
+	}
+	members := make([]string, 0, len(t.PossibleTypes))
+	for _, p := range t.PossibleTypes {
+		members = append(members, p.Name)
+	}
+	return members
+}
+
+// ResolveTypeName reconstruye la notación GraphQL del tipo (p. ej.
+// "[String!]!"), envolviendo LIST en corchetes y NON_NULL con "!" en vez de
+// desenvolverlos hasta el nombre desnudo: una declaración de variable que
+// omite esos envoltorios no coincide con cómo se usa la variable, y el
+// servidor rechaza la query entera por validación antes de que la
+// autorización entre en juego.
+func ResolveTypeName(t TypeRef) string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType == nil {
+			return t.Name
+		}
+		return ResolveTypeName(*t.OfType) + "!"
+	case "LIST":
+		if t.OfType == nil {
+			return t.Name
+		}
+		return "[" + ResolveTypeName(*t.OfType) + "]"
+	default:
+		if t.Name != "" {
+			return t.Name
+		}
+		if t.OfType != nil {
+			return ResolveTypeName(*t.OfType)
+		}
+		return ""
+	}
+}
+
+// DummyValue genera un valor de relleno válido para el tipo dado,
+// resolviendo INPUT_OBJECTs de forma recursiva a través del schema
+// cacheado y enums con un valor real tomado de EnumValues. visited
+// rastrea los nombres de tipo ya recorridos en esta rama para cortar
+// ciclos (A contiene B contiene A): el segundo encuentro se sustituye
+// por nil en vez de recursar indefinidamente.
+func (c *SchemaClient) DummyValue(t TypeRef, visited map[string]bool) interface{} {
+	if visited == nil {
+		visited = make(map[string]bool)
+	}
+
+	if t.Kind == "NON_NULL" && t.OfType != nil {
+		return c.DummyValue(*t.OfType, visited)
+	}
+
+	if t.Kind == "LIST" && t.OfType != nil {
+		element := c.DummyValue(*t.OfType, visited)
+		return []interface{}{element, element}
+	}
+
+	switch t.Kind {
+	case "SCALAR":
+		return dummyValueForScalar(t.Name)
+	case "ENUM":
+		values := c.EnumValues(t.Name)
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	case "INPUT_OBJECT":
+		if visited[t.Name] {
+			return nil
+		}
+		visited[t.Name] = true
+		defer delete(visited, t.Name)
+
+		dummy := make(map[string]interface{})
+		for _, field := range c.InputFields(t.Name) {
+			dummy[field.Name] = c.DummyValue(field.Type, visited)
+		}
+		return dummy
+	default:
+		if t.OfType != nil {
+			return c.DummyValue(*t.OfType, visited)
+		}
+		return "dummy"
+	}
+}
+
+// dummyValueForScalar retorna un valor dummy según el tipo escalar.
+func dummyValueForScalar(typeName string) interface{} {
+	switch typeName {
+	case "String", "ID":
+		return "gqlmsTestValue"
+	case "Int":
+		return 0
+	case "Float":
+		return 0.0
+	case "Boolean":
+		return false
+	default:
+		return "gqlmsTestValue"
+	}
+}
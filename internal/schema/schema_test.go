@@ -0,0 +1,64 @@
+package schema
+
+import "testing"
+
+func TestResolveTypeNameWrapsListAndNonNull(t *testing.T) {
+	cases := []struct {
+		name string
+		in   TypeRef
+		want string
+	}{
+		{
+			name: "bare scalar",
+			in:   TypeRef{Kind: "SCALAR", Name: "String"},
+			want: "String",
+		},
+		{
+			name: "non-null scalar",
+			in:   TypeRef{Kind: "NON_NULL", OfType: &TypeRef{Kind: "SCALAR", Name: "String"}},
+			want: "String!",
+		},
+		{
+			name: "list of non-null, itself non-null",
+			in: TypeRef{Kind: "NON_NULL", OfType: &TypeRef{Kind: "LIST", OfType: &TypeRef{
+				Kind: "NON_NULL", OfType: &TypeRef{Kind: "SCALAR", Name: "String"},
+			}}},
+			want: "[String!]!",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ResolveTypeName(tc.in); got != tc.want {
+				t.Errorf("ResolveTypeName(%+v) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDummyValueCutsCycles(t *testing.T) {
+	c := NewSchemaClient("http://example.invalid/graphql", nil, "", false)
+	c.indexTypes([]FullType{
+		{
+			Kind: "INPUT_OBJECT",
+			Name: "NodeInput",
+			InputFields: []Field{
+				{Name: "label", Type: TypeRef{Kind: "SCALAR", Name: "String"}},
+				{Name: "parent", Type: TypeRef{Kind: "INPUT_OBJECT", Name: "NodeInput"}},
+			},
+		},
+	})
+
+	// Si DummyValue no cortara el ciclo, esta llamada recursaría hasta un
+	// stack overflow en vez de devolver; el propio test sirve de
+	// regresión para eso.
+	got := c.DummyValue(TypeRef{Kind: "INPUT_OBJECT", Name: "NodeInput"}, nil)
+
+	dummy, ok := got.(map[string]interface{})
+	if !ok {
+		t.Fatalf("DummyValue returned %T, want map[string]interface{}", got)
+	}
+	if dummy["parent"] != nil {
+		t.Errorf("DummyValue[\"parent\"] = %v, want nil at the self-reference", dummy["parent"])
+	}
+}
@@ -0,0 +1,145 @@
+// Package wsgql abre una suscripción GraphQL sobre el protocolo
+// graphql-transport-ws para decidir si el servidor la autoriza o la
+// rechaza, ya que ese chequeo no puede hacerse con una petición HTTP
+// normal.
+package wsgql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// deniedCloseCodes son los códigos de cierre que graphql-transport-ws
+// reserva para rechazos de autenticación/autorización.
+var deniedCloseCodes = map[int]bool{
+	4401: true, // Unauthorized
+	4403: true, // Forbidden
+}
+
+type connectionInitMessage struct {
+	Type    string            `json:"type"`
+	Payload map[string]string `json:"payload,omitempty"`
+}
+
+type subscribeMessage struct {
+	ID      string           `json:"id"`
+	Type    string           `json:"type"`
+	Payload subscribePayload `json:"payload"`
+}
+
+type subscribePayload struct {
+	OperationName string                 `json:"operationName,omitempty"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type inboundMessage struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// TestSubscription abre una conexión graphql-transport-ws contra endpoint,
+// envía connection_init y luego subscribe con query/variables, y clasifica
+// el resultado: un mensaje "next" se considera permitido, un "error" o un
+// cierre con código 4401/4403 se considera denegado. proxy/useProxy siguen
+// la misma convención que sendRequest, para que el tráfico de suscripciones
+// pase por el mismo proxy (p. ej. Burp/ZAP) que mutations y queries.
+func TestSubscription(endpoint string, headers map[string]string, operationName, query string, variables map[string]interface{}, timeout time.Duration, proxy string, useProxy bool) (bool, error) {
+	wsURL, err := toWebSocketURL(endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	dialer := websocket.Dialer{
+		Subprotocols:     []string{"graphql-transport-ws"},
+		HandshakeTimeout: timeout,
+	}
+
+	if useProxy {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return false, fmt.Errorf("❌ Invalid proxy URL: %v", err)
+		}
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	reqHeader := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		reqHeader[k] = []string{v}
+	}
+
+	conn, _, err := dialer.Dial(wsURL, reqHeader)
+	if err != nil {
+		return false, fmt.Errorf("❌ error dialing subscription websocket: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	if err := conn.WriteJSON(connectionInitMessage{Type: "connection_init"}); err != nil {
+		return false, fmt.Errorf("❌ error sending connection_init: %w", err)
+	}
+
+	if err := conn.WriteJSON(subscribeMessage{
+		ID:   "1",
+		Type: "subscribe",
+		Payload: subscribePayload{
+			OperationName: operationName,
+			Query:         query,
+			Variables:     variables,
+		},
+	}); err != nil {
+		return false, fmt.Errorf("❌ error sending subscribe: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("❌ timed out waiting for subscription response")
+		default:
+		}
+
+		var msg inboundMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if closeErr, ok := err.(*websocket.CloseError); ok && deniedCloseCodes[closeErr.Code] {
+				return false, nil
+			}
+			return false, fmt.Errorf("❌ error reading subscription response: %w", err)
+		}
+
+		switch msg.Type {
+		case "connection_ack", "ping":
+			continue
+		case "next":
+			return true, nil
+		case "error":
+			return false, nil
+		default:
+			continue
+		}
+	}
+}
+
+// toWebSocketURL reescribe un endpoint http(s):// a ws(s)://.
+func toWebSocketURL(endpoint string) (string, error) {
+	switch {
+	case strings.HasPrefix(endpoint, "https://"):
+		return "wss://" + strings.TrimPrefix(endpoint, "https://"), nil
+	case strings.HasPrefix(endpoint, "http://"):
+		return "ws://" + strings.TrimPrefix(endpoint, "http://"), nil
+	case strings.HasPrefix(endpoint, "ws://"), strings.HasPrefix(endpoint, "wss://"):
+		return endpoint, nil
+	default:
+		return "", fmt.Errorf("❌ cannot derive websocket URL from endpoint %q", endpoint)
+	}
+}
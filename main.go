@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,55 +12,34 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ScarlyCodex/gqlms/internal/diffauth"
+	"github.com/ScarlyCodex/gqlms/internal/ratelimit"
+	"github.com/ScarlyCodex/gqlms/internal/report"
+	"github.com/ScarlyCodex/gqlms/internal/roles"
+	"github.com/ScarlyCodex/gqlms/internal/schema"
+	"github.com/ScarlyCodex/gqlms/internal/wsgql"
 	"github.com/fatih/color"
 	"github.com/schollz/progressbar/v3"
+	"golang.org/x/sync/errgroup"
 )
 
-// GraphQLType es un tipo genérico para la información de introspección.
-type GraphQLType struct {
-	Kind   string       `json:"kind"`
-	Name   string       `json:"name"`
-	OfType *GraphQLType `json:"ofType,omitempty"`
+// Operation envuelve un schema.Field con el tipo de operación al que
+// pertenece (mutation, query o subscription), ya que las tres se prueban
+// con la misma maquinaria pero necesitan un verbo de documento y, en el
+// caso de subscription, un transporte distinto.
+type Operation struct {
+	schema.Field
+	Kind string // "mutation", "query" o "subscription"
 }
 
-// Mutation representa una mutación GraphQL.
-type Mutation struct {
-	Name string `json:"name"`
-	Args []struct {
-		Name string      `json:"name"`
-		Type GraphQLType `json:"type"`
-	} `json:"args"`
-}
-
-// IntrospectionResponse representa la respuesta de la introspección para las mutaciones.
-type IntrospectionResponse struct {
-	Data struct {
-		Schema struct {
-			MutationType struct {
-				Fields []Mutation `json:"fields"`
-			} `json:"mutationType"`
-		} `json:"__schema"`
-	} `json:"data"`
-}
-
-// InputField representa un campo de un input object.
-type InputField struct {
-	Name string      `json:"name"`
-	Type GraphQLType `json:"type"`
-}
-
-// IntrospectionInputResponse representa la respuesta de la introspección de un input type.
-type IntrospectionInputResponse struct {
-	Data struct {
-		Type struct {
-			Name        string       `json:"name"`
-			InputFields []InputField `json:"inputFields"`
-		} `json:"__type"`
-	} `json:"data"`
-}
+// subscriptionTimeout acota cuánto se espera por un "next"/"error" antes de
+// considerar una suscripción inconclusa.
+const subscriptionTimeout = 10 * time.Second
 
 // GraphQLResponse es la estructura para parsear respuestas de GraphQL.
 type GraphQLResponse struct {
@@ -141,16 +121,32 @@ func printBanner() {
 func main() {
 	printBanner()
 	requestFile := flag.String("r", "", "Path to the HTTP request file (e.g., request.txt)")
-	delay := flag.Int("t", 1, "Time (in seconds) between each request")
+	workers := flag.Int("workers", 5, "Number of concurrent workers testing operations")
+	rps := flag.Float64("rps", 5, "Global requests-per-second limit across all workers")
 	useSSL := flag.Bool("ssl", true, "Use HTTPS (default: true). Use -ssl=false to disable SSL resolution")
-	unauthHeaders := flag.String("unauth", "", "Comma-separated list of authentication-related headers to remove after introspection")
 	verboseFlag := flag.Bool("v", false, "Enable verbose logging of responses for analysis")
+	schemaFile := flag.String("schema-file", "", "Path to a cached schema.json. Read if present, otherwise introspected and written there for offline reruns")
+	opFlag := flag.String("op", "mutation", "Operation types to test: mutation, query, subscription, or all")
+	rolesFile := flag.String("roles", "", "Path to a roles.yaml defining named identities to test every operation against (e.g. admin, user, guest, anon)")
+	outputFormat := flag.String("output", "", "Emit a structured report in this format: json, sarif or md (default: none, keep the legacy allowed/unallowed .txt files only)")
+	outputPath := flag.String("o", "", "Path to write the -output report to (default: report.<format>)")
+	diffMode := flag.Bool("diff", false, "Classify by response-shape similarity instead of string matching: record an authenticated success/invalid-input signature per operation and compare against it")
+	diffThreshold := flag.Float64("diff-threshold", 0.6, "Minimum Jaccard similarity to a baseline signature for -diff to classify as allowed/denied, otherwise inconclusive")
 
 	var proxy ProxyFlag
 	flag.Var(&proxy, "proxy", "Use proxy. Use -proxy= (default: http://127.0.0.1:8080) or -proxy=http://custom:port")
 	flag.Parse()
 	verbose = *verboseFlag
 
+	// -workers 0 deja errgroup.SetLimit(0) instalando un semáforo sin buffer:
+	// el primer g.Go() se queda esperando para siempre a que alguien lo lea,
+	// y un valor negativo hace panic en make(chan ..., workers). Ninguno de
+	// los dos es un "límite" válido, así que se flotan a 1 igual que
+	// ratelimit.New flota un -rps<=0 a un valor por defecto.
+	if *workers < 1 {
+		*workers = 1
+	}
+
 	if *requestFile == "" {
 		fmt.Println("Error: You must provide a path to the request file using -r")
 		os.Exit(1)
@@ -174,130 +170,281 @@ func main() {
 		fmt.Println(" → No proxy in use")
 	}
 
-	// Parse unauth headers if set
-	var unauthList []string
-	if *unauthHeaders != "" {
-		unauthList = strings.Split(*unauthHeaders, ",")
-		for i := range unauthList {
-			unauthList[i] = strings.TrimSpace(unauthList[i])
-		}
+	// Step 1: obtener el schema completo (cacheado si -schema-file existe)
+	schemaClient := schema.NewSchemaClient(endpoint, headers, proxy.URL, proxy.Enabled)
+	if err := schemaClient.Load(*schemaFile); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	operations, err := getOperations(schemaClient, *opFlag)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
 
-		// Warn if headers not found
-		missing := []string{}
-		for _, h := range unauthList {
-			if _, ok := headers[h]; !ok {
-				missing = append(missing, h)
-			}
+	if *outputFormat != "" && *outputFormat != "json" && *outputFormat != "sarif" && *outputFormat != "md" {
+		fmt.Printf("❌ unknown -output value %q (want json, sarif or md)\n", *outputFormat)
+		os.Exit(1)
+	}
+
+	// -output vuelca un []report.Result por operación; la matriz de -roles
+	// tiene una forma distinta (una fila por operación con una columna por
+	// rol) y ya escribe la suya propia vía writeRoleMatrixReport, así que
+	// combinar ambas silenciosamente ignoraría -output en vez de avisar.
+	if *outputFormat != "" && *rolesFile != "" {
+		fmt.Println("❌ -output is not supported together with -roles; -roles already writes its own report.json/report.md matrix")
+		os.Exit(1)
+	}
+
+	// Step 2: probar las operaciones. Con -roles se prueba cada una una vez
+	// por identidad y se emite una matriz de autorización; sin -roles se
+	// conserva el comportamiento de una sola pasada con los headers de la
+	// petición tal cual. Con -diff, antes de probar nada se graba, por
+	// operación, cómo luce una respuesta exitosa y cómo luce un rechazo bien
+	// formado usando la identidad de mayor privilegio disponible, y esa firma
+	// reemplaza el heurístico de string matching al clasificar el resto de
+	// las respuestas.
+	if *rolesFile != "" {
+		roleList, err := roles.Load(*rolesFile)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
 		}
 
-		if len(missing) > 0 {
-			fmt.Printf("\n⚠️ The following headers were not found in the request: %v\n", missing)
-			fmt.Print("Do you want to continue anyway? [Y/n]: ")
-			var response string
-			fmt.Scanln(&response)
-
-			switch strings.ToLower(response) {
-			case "y":
-				// continue
-			case "n":
-				fmt.Println("🛑 Aborting.")
-				os.Exit(0)
-			default:
-				fmt.Println("❌ Invalid input. Please use Y or n.")
+		var classifier *diffauth.Classifier
+		if *diffMode {
+			baselineHeaders := topRole(roleList).Overlay(headers)
+			classifier, err = buildDiffClassifier(schemaClient, operations, endpoint, baselineHeaders, proxy.URL, proxy.Enabled, *rps, *diffThreshold)
+			if err != nil {
+				fmt.Printf("%v\n", err)
 				os.Exit(1)
 			}
 		}
-	}
 
-	// Step 1: obtener el schema autenticado
-	mutations := getMutations(endpoint, headers, proxy.URL, proxy.Enabled)
+		if err := testOperationsAcrossRoles(schemaClient, operations, endpoint, headers, roleList, *workers, *rps, proxy.URL, proxy.Enabled, classifier); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Step 2: remover headers para pruebas sin auth
-	if len(unauthList) > 0 {
-		fmt.Println("🔓 Switching to unauthenticated mode. Removing headers:", unauthList)
-		for _, h := range unauthList {
-			delete(headers, h)
+	var classifier *diffauth.Classifier
+	if *diffMode {
+		var buildErr error
+		classifier, buildErr = buildDiffClassifier(schemaClient, operations, endpoint, headers, proxy.URL, proxy.Enabled, *rps, *diffThreshold)
+		if buildErr != nil {
+			fmt.Printf("%v\n", buildErr)
+			os.Exit(1)
 		}
 	}
 
-	// Step 3: probar mutaciones en modo no autenticado
-	testMutations(mutations, endpoint, headers, *delay, baseRequestBody, proxy.URL, proxy.Enabled)
+	testOperations(schemaClient, operations, endpoint, headers, *workers, *rps, baseRequestBody, proxy.URL, proxy.Enabled, *outputFormat, *outputPath, classifier)
 }
 
-// getMutations obtiene las mutaciones vía introspección
-func getMutations(endpoint string, headers map[string]string, proxy string, useProxy bool) []Mutation {
-	query := map[string]string{
-		"query": `{ __schema { mutationType { fields { name args { name type { name kind ofType { name } } } } } } }`,
+// topRole devuelve el rol de mayor Level de roleList, asumido la identidad
+// más privilegiada y por tanto la fuente correcta para el baseline de -diff
+// (su respuesta "exitosa" es la que de verdad debería tener acceso).
+func topRole(roleList []roles.Role) roles.Role {
+	top := roleList[0]
+	for _, r := range roleList {
+		if r.Level > top.Level {
+			top = r
+		}
 	}
-	queryBytes, _ := json.Marshal(query)
+	return top
+}
 
-	resp, err := sendRequest(endpoint, headers, queryBytes, proxy, useProxy)
-	if err != nil {
-		fmt.Printf("❌ Error fetching mutations: %v\n", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
+// operationFileNames asocia cada tipo de operación con el nombre en plural
+// que usan los ficheros all/allowed/unallowed (allMutations.txt, etc).
+var operationFileNames = map[string]string{
+	"mutation":     "Mutations",
+	"query":        "Queries",
+	"subscription": "Subscriptions",
+}
 
-	responseBody, _ := io.ReadAll(resp.Body)
+// getOperations enumera los campos de mutationType/queryType/subscriptionType
+// que pide -op (mutation, query, subscription o all), ya resueltos por el
+// SchemaClient, y los vuelca a all<Kind>.txt para referencia.
+func getOperations(schemaClient *schema.SchemaClient, opFlag string) ([]Operation, error) {
+	wanted := map[string]bool{}
+	switch opFlag {
+	case "all":
+		wanted["mutation"], wanted["query"], wanted["subscription"] = true, true, true
+	case "mutation", "query", "subscription":
+		wanted[opFlag] = true
+	default:
+		return nil, fmt.Errorf("❌ unknown -op value %q (want mutation, query, subscription or all)", opFlag)
+	}
 
-	var introspectionResp IntrospectionResponse
-	json.Unmarshal(responseBody, &introspectionResp)
+	var operations []Operation
+	for _, kind := range []string{"mutation", "query", "subscription"} {
+		if !wanted[kind] {
+			continue
+		}
 
-	file, _ := os.Create("allMutations.txt")
-	defer file.Close()
-	writer := bufio.NewWriter(file)
+		var fields []schema.Field
+		switch kind {
+		case "mutation":
+			fields = schemaClient.Mutations()
+		case "query":
+			fields = schemaClient.Queries()
+		case "subscription":
+			fields = schemaClient.Subscriptions()
+		}
 
-	mutations := introspectionResp.Data.Schema.MutationType.Fields
-	for _, field := range mutations {
-		writer.WriteString(field.Name + "\n")
+		file, _ := os.Create("all" + operationFileNames[kind] + ".txt")
+		writer := bufio.NewWriter(file)
+		for _, field := range fields {
+			writer.WriteString(field.Name + "\n")
+			operations = append(operations, Operation{Field: field, Kind: kind})
+		}
+		writer.Flush()
+		file.Close()
 	}
-	writer.Flush()
 
-	fmt.Println("\n✅ Successfully fetched mutations. Now testing authorization...\n")
-	return mutations
+	fmt.Println("\n✅ Successfully fetched operations. Now testing authorization...\n")
+	return operations, nil
 }
 
-func dummyValueForType(t GraphQLType) interface{} {
-	if t.Kind == "NON_NULL" && t.OfType != nil {
-		return dummyValueForType(*t.OfType)
+// operationArgTypes resume los tipos de los argumentos de una operación
+// (p. ej. {"id": "ID", "input": "UpdateUserInput"}) para que el reporte
+// estructurado no obligue a volver a parsear el payload.
+func operationArgTypes(operation Operation) map[string]string {
+	if len(operation.Args) == 0 {
+		return nil
+	}
+	types := make(map[string]string, len(operation.Args))
+	for _, arg := range operation.Args {
+		types[arg.Name] = schema.ResolveTypeName(arg.Type)
 	}
+	return types
+}
 
-	if t.Kind == "LIST" && t.OfType != nil {
-		element := dummyValueForType(*t.OfType)
-		return []interface{}{element, element}
+// testOperation prueba una única operación con el transporte que
+// corresponda a su Kind y devuelve un booleano junto con el detalle
+// completo para el reporte estructurado (payload, status, clasificación y
+// la heurística que la disparó). El booleano es false para "denied" y
+// cuando no se obtiene respuesta (fallo de transporte, rate-limit agotado,
+// error del websocket); para una respuesta HTTP recibida es true en toda
+// clasificación distinta de "denied", incluidas "inconclusive" y "error"
+// (5xx), así que sólo detail.Classification distingue un permiso real.
+// Las mutations y queries se prueban por
+// HTTP, con reintento y backoff si el servidor señala rate-limit; las
+// subscriptions se prueban abriendo un websocket graphql-transport-ws y no
+// se reintentan, ya que ese transporte no expone un código de estado HTTP
+// sobre el que aplicar la misma heurística.
+//
+// classifier es opcional (nil salvo -diff): cuando está presente reemplaza
+// el heurístico de string matching por la similitud de la firma de la
+// respuesta contra los baselines grabados para esa operación.
+func testOperation(schemaClient *schema.SchemaClient, operation Operation, endpoint string, headers map[string]string, proxy string, useProxy bool, limiter *ratelimit.AdaptiveLimiter, classifier *diffauth.Classifier) (bool, report.Result) {
+	detail := report.Result{Name: operation.Name, Kind: operation.Kind, ArgTypes: operationArgTypes(operation)}
+
+	if operation.Kind == "subscription" {
+		query, variables := buildOperationDocument(schemaClient, operation)
+		payloadBytes, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		detail.Payload = string(payloadBytes)
+
+		if err := limiter.Wait(context.Background()); err != nil {
+			detail.Classification = report.ErrorStatus
+			return false, detail
+		}
+		allowed, err := wsgql.TestSubscription(endpoint, headers, operation.Name, query, variables, subscriptionTimeout, proxy, useProxy)
+		limiter.OnSuccess()
+		if err != nil {
+			if verbose {
+				fmt.Printf("\nSubscription %s error: %v\n", operation.Name, err)
+			}
+			detail.Classification = report.Inconclusive
+			detail.Heuristic = "websocket_error"
+			return false, detail
+		}
+		if allowed {
+			detail.Classification = report.Allowed
+		} else {
+			detail.Classification = report.Denied
+			detail.Heuristic = "websocket_error_or_close_code"
+		}
+		return allowed, detail
 	}
 
-	switch t.Kind {
-	case "SCALAR":
-		return dummyValueForScalar(t.Name)
-	case "ENUM":
-		return "ENUM_VALUE"
-	case "INPUT_OBJECT":
-		return map[string]interface{}{} // Rellenado dinámicamente
-	default:
-		if t.OfType != nil {
-			return dummyValueForType(*t.OfType)
+	const maxAttempts = 5
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			detail.Classification = report.ErrorStatus
+			return false, detail
+		}
+
+		payload := buildOperationPayload(schemaClient, operation)
+		detail.Payload = string(payload)
+		resp, err := sendRequest(endpoint, headers, payload, proxy, useProxy)
+
+		if err == nil && isRateLimited(resp) {
+			limiter.OnRateLimited()
+			time.Sleep(ratelimit.Backoff(attempt))
+			continue
+		}
+
+		limiter.OnSuccess()
+		if err != nil {
+			detail.Classification = report.ErrorStatus
+			return false, detail
+		}
+
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+		// Si verbose está activo, se imprime la respuesta completa para análisis
+		if verbose {
+			fmt.Printf("\nResponse for %s %s:\n%s\n", operation.Kind, operation.Name, string(bodyBytes))
+		}
+
+		detail.HTTPStatus = resp.StatusCode
+
+		if classifier != nil {
+			signature := diffauth.ExtractSignature(resp.StatusCode, bodyBytes)
+			classification, successSim, invalidSim := classifier.Classify(operation.Kind, operation.Name, signature)
+			detail.Classification = classification
+			detail.Heuristic = fmt.Sprintf("diff(success=%.2f, invalid=%.2f)", successSim, invalidSim)
+			detail.ErrorCodes, detail.ErrorMessages = parseGraphQLErrors(bodyBytes)
+			return classification != report.Denied, detail
 		}
-		return "dummy"
+
+		classification, heuristic, codes, messages := classifyResponse(resp)
+		detail.Classification = classification
+		detail.Heuristic = heuristic
+		detail.ErrorCodes = codes
+		detail.ErrorMessages = messages
+		return classification != report.Denied, detail
 	}
-}
 
+	detail.Classification = report.ErrorStatus
+	detail.Heuristic = "rate_limited_exhausted"
+	return false, detail
+}
 
+// operationOutcome es el mensaje que cada worker envía al goroutine
+// escritor, que serializa todas las escrituras a disco y al progress bar.
+type operationOutcome struct {
+	operation Operation
+	detail    report.Result
+}
 
-// testMutations prueba cada mutación y registra el resultado
-func testMutations(mutations []Mutation, endpoint string, headers map[string]string, delay int, baseRequestBody string, proxy string, useProxy bool) {
-	allowedCount := 0
-	unallowedCount := 0
-	allowedFile, _ := os.Create("allowedMutations.txt")
-	defer allowedFile.Close()
-	allowedWriter := bufio.NewWriter(allowedFile)
+func testOperations(schemaClient *schema.SchemaClient, operations []Operation, endpoint string, headers map[string]string, workers int, rps float64, baseRequestBody string, proxy string, useProxy bool, outputFormat, outputPath string, classifier *diffauth.Classifier) {
+	allowedWriters := make(map[string]*bufio.Writer)
+	unallowedWriters := make(map[string]*bufio.Writer)
+	for kind, plural := range operationFileNames {
+		allowedFile, _ := os.Create("allowed" + plural + ".txt")
+		defer allowedFile.Close()
+		allowedWriters[kind] = bufio.NewWriter(allowedFile)
 
-	unallowedFile, _ := os.Create("unallowedMutations.txt")
-	defer unallowedFile.Close()
-	unallowedWriter := bufio.NewWriter(unallowedFile)
+		unallowedFile, _ := os.Create("unallowed" + plural + ".txt")
+		defer unallowedFile.Close()
+		unallowedWriters[kind] = bufio.NewWriter(unallowedFile)
+	}
 
-	bar := progressbar.NewOptions(len(mutations),
-		progressbar.OptionSetDescription("🔄 Testing mutations"),
+	bar := progressbar.NewOptions(len(operations),
+		progressbar.OptionSetDescription("🔄 Testing operations"),
 		progressbar.OptionSetTheme(progressbar.Theme{
 			Saucer:        "🔹",
 			SaucerHead:    "🔸",
@@ -310,82 +457,234 @@ func testMutations(mutations []Mutation, endpoint string, headers map[string]str
 		progressbar.OptionSetPredictTime(true),
 		progressbar.OptionSetElapsedTime(true),
 	)
+	var barMu sync.Mutex
 
-	for _, mutation := range mutations {
-		bar.Describe(fmt.Sprintf("🔄 %s", mutation.Name))
-
-		// Se construye el payload dinámicamente usando la información real de los argumentos
-		payload := buildMutationPayload(mutation, endpoint, headers, baseRequestBody, proxy, useProxy)
-		resp, err := sendRequest(endpoint, headers, payload, proxy, useProxy)
-
-		// Si verbose está activo, se imprime la respuesta completa para análisis
-		if verbose {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			fmt.Printf("\nResponse for mutation %s:\n%s\n", mutation.Name, string(bodyBytes))
-			resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		}
+	allowedCount := 0
+	unallowedCount := 0
+	var results []report.Result
+	outcomes := make(chan operationOutcome, workers)
+	var writerWG sync.WaitGroup
+	writerWG.Add(1)
+	go func() {
+		defer writerWG.Done()
+		for outcome := range outcomes {
+			// allowed sólo cuenta la clasificación "allowed" real: el booleano
+			// que devuelve testOperation también es true para "inconclusive"
+			// (p. ej. un 400) y "error" (5xx), que no son
+			// un permiso real (ya corregido para la matriz de roles en 7206c77).
+			if outcome.detail.Classification == report.Allowed {
+				allowedWriters[outcome.operation.Kind].WriteString(outcome.operation.Name + "\n")
+				allowedWriters[outcome.operation.Kind].Flush()
+				allowedCount++
+			} else {
+				unallowedWriters[outcome.operation.Kind].WriteString(outcome.operation.Name + "\n")
+				unallowedWriters[outcome.operation.Kind].Flush()
+				unallowedCount++
+			}
+			results = append(results, outcome.detail)
 
-		if err != nil || containsDeniedMessage(resp) {
-			unallowedWriter.WriteString(mutation.Name + "\n")
-			unallowedWriter.Flush()
-			unallowedCount++
-		} else {
-			allowedWriter.WriteString(mutation.Name + "\n")
-			allowedWriter.Flush()
-			allowedCount++
+			barMu.Lock()
+			bar.Add(1)
+			barMu.Unlock()
 		}
-
-		bar.Add(1)
-		time.Sleep(time.Duration(delay) * time.Second)
-	}
+	}()
+
+	limiter := ratelimit.New(rps)
+	g := new(errgroup.Group)
+	g.SetLimit(workers)
+	for _, operation := range operations {
+		operation := operation
+		g.Go(func() error {
+			_, detail := testOperation(schemaClient, operation, endpoint, headers, proxy, useProxy, limiter, classifier)
+			outcomes <- operationOutcome{operation: operation, detail: detail}
+			return nil
+		})
+	}
+	g.Wait()
+	close(outcomes)
+	writerWG.Wait()
 
 	fmt.Println("\n✅ Authorization testing completed!")
 	fmt.Printf("\n📊 Summary:\n")
 	fmt.Printf("  ✅ Allowed:     %d\n", allowedCount)
 	fmt.Printf("  ❌ Unauthorized: %d\n", unallowedCount)
 	fmt.Printf("  📦 Total tested: %d\n\n", allowedCount+unallowedCount)
+
+	if outputFormat != "" {
+		if err := report.Write(results, outputFormat, outputPath, "report."+outputFormat); err != nil {
+			fmt.Printf("%v\n", err)
+		}
+	}
 }
 
-// buildMutationPayload construye el payload de la mutación usando la definición real de argumentos
-func buildMutationPayload(mutation Mutation, endpoint string, headers map[string]string, baseRequestBody string, proxy string, useProxy bool) []byte {
-	variables := make(map[string]interface{})
-	var varDefs []string
-	var argsList []string
+// OperationResult es una fila de la matriz de autorización: una operación y,
+// por rol, si fue permitida. Candidates lista los roles de privilegio menor
+// al más alto configurado para los que la operación fue permitida — BOLA/BFLA
+// a revisar a mano, no un hallazgo confirmado.
+type OperationResult struct {
+	Name       string          `json:"name"`
+	Kind       string          `json:"kind"`
+	AllowedBy  map[string]bool `json:"allowedBy"`
+	Candidates []string        `json:"candidates,omitempty"`
+}
+
+// roleJob es una pareja (operación, rol) a probar; aplanar la matriz en
+// jobs independientes es lo que permite repartirla sobre el worker pool.
+type roleJob struct {
+	opIndex   int
+	operation Operation
+	role      roles.Role
+}
+
+// testOperationsAcrossRoles prueba cada operación una vez por rol definido
+// en roleList y escribe la matriz resultante en report.json y report.md.
+func testOperationsAcrossRoles(schemaClient *schema.SchemaClient, operations []Operation, endpoint string, baseHeaders map[string]string, roleList []roles.Role, workers int, rps float64, proxy string, useProxy bool, classifier *diffauth.Classifier) error {
+	topLevel := roles.MaxLevel(roleList)
+
+	results := make([]OperationResult, len(operations))
+	var jobs []roleJob
+	for i, operation := range operations {
+		results[i] = OperationResult{
+			Name:      operation.Name,
+			Kind:      operation.Kind,
+			AllowedBy: make(map[string]bool, len(roleList)),
+		}
+		for _, role := range roleList {
+			jobs = append(jobs, roleJob{opIndex: i, operation: operation, role: role})
+		}
+	}
 
-	for _, arg := range mutation.Args {
-		typeName := resolveTypeName(arg.Type)
+	bar := progressbar.NewOptions(len(jobs),
+		progressbar.OptionSetDescription("🔄 Testing operations across roles"),
+		progressbar.OptionSetWidth(40),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionSetElapsedTime(true),
+	)
+	var barMu sync.Mutex
+	var resultsMu sync.Mutex
+
+	limiter := ratelimit.New(rps)
+	g := new(errgroup.Group)
+	g.SetLimit(workers)
+	for _, job := range jobs {
+		job := job
+		g.Go(func() error {
+			headers := job.role.Overlay(baseHeaders)
+			_, detail := testOperation(schemaClient, job.operation, endpoint, headers, proxy, useProxy, limiter, classifier)
+
+			// allowed sólo cuenta la clasificación "allowed" real: el booleano
+			// que devuelve testOperation también es true para "inconclusive"
+			// (p. ej. un 400) y "error" (5xx), que no son
+			// un permiso real y no deberían poder marcar un candidato BOLA/BFLA.
+			allowed := detail.Classification == report.Allowed
+
+			resultsMu.Lock()
+			results[job.opIndex].AllowedBy[job.role.Name] = allowed
+			if allowed && job.role.Level < topLevel {
+				results[job.opIndex].Candidates = append(results[job.opIndex].Candidates, job.role.Name)
+			}
+			resultsMu.Unlock()
 
-		if arg.Type.Kind == "INPUT_OBJECT" || (arg.Type.Kind == "NON_NULL" && arg.Type.OfType != nil && arg.Type.OfType.Kind == "INPUT_OBJECT") {
-			inputTypeName := typeName
-			inputFieldsData := getInputFields(inputTypeName, endpoint, headers, proxy, useProxy)
-			dummyObj := make(map[string]interface{})
+			barMu.Lock()
+			bar.Add(1)
+			barMu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+
+	for i := range results {
+		sort.Strings(results[i].Candidates)
+	}
 
-			for _, field := range inputFieldsData {
-				dummyObj[field.Name] = dummyValueForType(field.Type)
+	fmt.Println("\n✅ Role matrix testing completed!")
+	return writeRoleMatrixReport(results, roleList)
+}
+
+// writeRoleMatrixReport escribe report.json (la matriz completa) y
+// report.md (una tabla humana que destaca los candidatos BOLA/BFLA).
+func writeRoleMatrixReport(results []OperationResult, roleList []roles.Role) error {
+	jsonBytes, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("❌ error building report.json: %w", err)
+	}
+	if err := os.WriteFile("report.json", jsonBytes, 0o644); err != nil {
+		return fmt.Errorf("❌ error writing report.json: %w", err)
+	}
+
+	var md strings.Builder
+	md.WriteString("# gqlms role authorization matrix\n\n")
+	md.WriteString("| Operation | Kind |")
+	for _, role := range roleList {
+		md.WriteString(fmt.Sprintf(" %s |", role.Name))
+	}
+	md.WriteString(" ⚠️ BOLA/BFLA candidates |\n")
+
+	md.WriteString("|---|---|")
+	for range roleList {
+		md.WriteString("---|")
+	}
+	md.WriteString("---|\n")
+
+	for _, result := range results {
+		md.WriteString(fmt.Sprintf("| %s | %s |", result.Name, result.Kind))
+		for _, role := range roleList {
+			mark := "❌"
+			if result.AllowedBy[role.Name] {
+				mark = "✅"
 			}
-			variables[arg.Name] = dummyObj
-		} else {
-			variables[arg.Name] = dummyValueForType(arg.Type)
+			md.WriteString(fmt.Sprintf(" %s |", mark))
 		}
-
-		nonNull := ""
-		if arg.Type.Kind == "NON_NULL" {
-			nonNull = "!"
+		candidates := "-"
+		if len(result.Candidates) > 0 {
+			candidates = strings.Join(result.Candidates, ", ")
 		}
+		md.WriteString(fmt.Sprintf(" %s |\n", candidates))
+	}
+
+	if err := os.WriteFile("report.md", []byte(md.String()), 0o644); err != nil {
+		return fmt.Errorf("❌ error writing report.md: %w", err)
+	}
 
-		varDefs = append(varDefs, fmt.Sprintf("$%s: %s%s", arg.Name, typeName, nonNull))
+	fmt.Println("📄 Wrote report.json and report.md")
+	return nil
+}
+
+// buildOperationDocument construye el documento GraphQL y las variables de
+// una operación usando la definición real de argumentos y el schema
+// cacheado (que resuelve INPUT_OBJECTs anidados y enums reales a través de
+// schemaClient.DummyValue).
+func buildOperationDocument(schemaClient *schema.SchemaClient, operation Operation) (string, map[string]interface{}) {
+	variables := make(map[string]interface{})
+	var varDefs []string
+	var argsList []string
+
+	for _, arg := range operation.Args {
+		typeName := schema.ResolveTypeName(arg.Type)
+		variables[arg.Name] = schemaClient.DummyValue(arg.Type, nil)
+
+		varDefs = append(varDefs, fmt.Sprintf("$%s: %s", arg.Name, typeName))
 		argsList = append(argsList, fmt.Sprintf("%s: $%s", arg.Name, arg.Name))
 	}
 
-	query := fmt.Sprintf("mutation %s(%s) { %s(%s) { __typename } }",
-		mutation.Name,
+	query := fmt.Sprintf("%s %s(%s) { %s(%s) { __typename } }",
+		operation.Kind,
+		operation.Name,
 		strings.Join(varDefs, ", "),
-		mutation.Name,
+		operation.Name,
 		strings.Join(argsList, ", "),
 	)
 
+	return query, variables
+}
+
+// buildOperationPayload construye el payload HTTP de una mutation/query.
+func buildOperationPayload(schemaClient *schema.SchemaClient, operation Operation) []byte {
+	query, variables := buildOperationDocument(schemaClient, operation)
+
 	payload := map[string]interface{}{
-		"operationName": mutation.Name,
+		"operationName": operation.Name,
 		"query":         query,
 		"variables":     variables,
 	}
@@ -394,84 +693,132 @@ func buildMutationPayload(mutation Mutation, endpoint string, headers map[string
 	return payloadBytes
 }
 
+// buildInvalidOperationPayload construye el mismo documento GraphQL que
+// buildOperationPayload pero con variables deliberadamente mal tipadas, de
+// forma que el servidor las rechace por coerción de tipos y no por
+// autorización. Esa respuesta es el baseline de "rechazo bien formado" que
+// -diff usa para distinguir un 403 disfrazado de 200 de un verdadero 200.
+func buildInvalidOperationPayload(schemaClient *schema.SchemaClient, operation Operation) []byte {
+	query, _ := buildOperationDocument(schemaClient, operation)
 
-// resolveTypeName obtiene el nombre real de un tipo GraphQL
-func resolveTypeName(t GraphQLType) string {
-	if t.Name != "" {
-		return t.Name
+	variables := make(map[string]interface{})
+	for _, arg := range operation.Args {
+		variables[arg.Name] = invalidValueForType(arg.Type)
 	}
-	if t.OfType != nil {
-		return t.OfType.Name
+
+	payload := map[string]interface{}{
+		"operationName": operation.Name,
+		"query":         query,
+		"variables":     variables,
 	}
-	return ""
+
+	payloadBytes, _ := json.Marshal(payload)
+	return payloadBytes
 }
 
-// dummyValueForScalar retorna un valor dummy según el tipo escalar
-func dummyValueForScalar(typeName string) interface{} {
-	switch typeName {
+// invalidValueForType devuelve un valor del tipo equivocado para t: un
+// entero donde se espera un string y viceversa, y un string arbitrario
+// para cualquier tipo compuesto (ENUM, INPUT_OBJECT, LIST), que tampoco
+// acepta un escalar suelto. No necesita ser un valor "creativo", sólo uno
+// que no sea el que el servidor espera. Se desenvuelve NON_NULL a mano en
+// vez de usar schema.ResolveTypeName, que desde que reconstruye la
+// notación "[...]"/"!" ya no devuelve el nombre desnudo con el que este
+// switch compara.
+func invalidValueForType(t schema.TypeRef) interface{} {
+	if t.Kind == "NON_NULL" && t.OfType != nil {
+		return invalidValueForType(*t.OfType)
+	}
+
+	switch t.Name {
 	case "String", "ID":
-		return "gqlmsTestValue"
-	case "Int":
-		return 0
-	case "Float":
-		return 0.0
+		return 1234567
+	case "Int", "Float":
+		return "not-a-number"
 	case "Boolean":
-		return false
+		return "not-a-bool"
 	default:
-		return "gqlmsTestValue"
+		return "__gqlms_invalid_input__"
 	}
 }
 
-// dummyValueForInputField retorna un valor dummy para un campo de input basado en su tipo
-func dummyValueForInputField(t GraphQLType) interface{} {
-	var actualType string
-	if t.Name != "" {
-		actualType = t.Name
-	} else if t.OfType != nil {
-		actualType = t.OfType.Name
+// sendRequestRespectingLimit envía payload con el mismo ritmo y la misma
+// política de reintento ante rate-limit que testOperation, para que grabar
+// baselines de -diff no dispare el mismo servidor que luego se va a medir
+// y termine grabando una página de rate-limit como si fuera el éxito.
+func sendRequestRespectingLimit(endpoint string, headers map[string]string, payload []byte, proxy string, useProxy bool, limiter *ratelimit.AdaptiveLimiter) (int, []byte, error) {
+	const maxAttempts = 5
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			return 0, nil, err
+		}
+
+		resp, err := sendRequest(endpoint, headers, payload, proxy, useProxy)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if isRateLimited(resp) {
+			limiter.OnRateLimited()
+			time.Sleep(ratelimit.Backoff(attempt))
+			continue
+		}
+
+		limiter.OnSuccess()
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, body, nil
 	}
-	return dummyValueForScalar(actualType)
+
+	return 0, nil, fmt.Errorf("❌ gave up after %d attempts, still rate-limited", maxAttempts)
 }
 
-// getInputFields realiza una introspección para obtener los inputFields de un input type
-func getInputFields(typeName, endpoint string, headers map[string]string, proxy string, useProxy bool) []InputField {
-	queryPayload := map[string]interface{}{
-		"query": `query IntrospectType($typeName: String!) {
-  __type(name: $typeName) {
-    name
-    inputFields {
-      name
-      type {
-        kind
-        name
-        ofType {
-          kind
-          name
-        }
-      }
-    }
-  }
-}`,
-		"variables": map[string]interface{}{
-			"typeName": typeName,
-		},
-	}
-	payloadBytes, _ := json.Marshal(queryPayload)
-	resp, err := sendRequest(endpoint, headers, payloadBytes, proxy, useProxy)
-	if err != nil {
-		fmt.Printf("❌ Error introspecting type %s: %v\n", typeName, err)
-		return []InputField{}
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var introspectionResp IntrospectionInputResponse
-	json.Unmarshal(body, &introspectionResp)
-	return introspectionResp.Data.Type.InputFields
+// buildDiffClassifier graba, para cada operación, la firma de una
+// respuesta con input válido (usando authHeaders, asumida la identidad más
+// privilegiada disponible) y la firma de una respuesta con input
+// deliberadamente inválido, y devuelve un Classifier listo para comparar
+// respuestas futuras contra ambos baselines. Usa su propio AdaptiveLimiter
+// a rps para no golpear el servidor más rápido de lo que el resto de la
+// corrida lo haría; de lo contrario un servidor que empieza a rate-limitar
+// a mitad de la grabación dejaría baselines corruptos (una página de
+// rate-limit grabada como si fuera un "éxito") sin que nada lo avise.
+func buildDiffClassifier(schemaClient *schema.SchemaClient, operations []Operation, endpoint string, authHeaders map[string]string, proxy string, useProxy bool, rps, threshold float64) (*diffauth.Classifier, error) {
+	classifier := diffauth.NewClassifier(threshold)
+	limiter := ratelimit.New(rps)
+	recorded := 0
+
+	for _, operation := range operations {
+		if operation.Kind == "subscription" {
+			continue
+		}
+
+		successPayload := buildOperationPayload(schemaClient, operation)
+		successStatus, successBody, err := sendRequestRespectingLimit(endpoint, authHeaders, successPayload, proxy, useProxy, limiter)
+		if err != nil {
+			return nil, fmt.Errorf("❌ error recording -diff success baseline for %s: %w", operation.Name, err)
+		}
+
+		invalidPayload := buildInvalidOperationPayload(schemaClient, operation)
+		invalidStatus, invalidBody, err := sendRequestRespectingLimit(endpoint, authHeaders, invalidPayload, proxy, useProxy, limiter)
+		if err != nil {
+			return nil, fmt.Errorf("❌ error recording -diff invalid-input baseline for %s: %w", operation.Name, err)
+		}
+
+		classifier.Record(operation.Kind, operation.Name,
+			diffauth.ExtractSignature(successStatus, successBody),
+			diffauth.ExtractSignature(invalidStatus, invalidBody),
+		)
+		recorded++
+	}
+
+	fmt.Println("📐 Recorded -diff baselines for", recorded, "operations")
+	return classifier, nil
 }
 
-// containsDeniedMessage detecta si la respuesta indica acceso no autorizado utilizando múltiples heurísticas
-func containsDeniedMessage(resp *http.Response) bool {
-	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+// isRateLimited detecta si la respuesta es un rechazo por rate-limit (HTTP
+// 429 o un error GraphQL con código RATE_LIMITED/TOO_MANY_REQUESTS), a
+// distinguir de una denegación de autorización para no contarla como tal.
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == 429 {
 		return true
 	}
 
@@ -480,21 +827,67 @@ func containsDeniedMessage(resp *http.Response) bool {
 
 	var gqlResp GraphQLResponse
 	if json.Unmarshal(responseBody, &gqlResp) == nil {
+		for _, errObj := range gqlResp.Errors {
+			code := strings.ToUpper(errObj.Extensions.Code)
+			if code == "RATE_LIMITED" || code == "TOO_MANY_REQUESTS" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseGraphQLErrors extrae los códigos y mensajes de errors[] de un body
+// ya leído, para que el modo -diff pueda anotar el reporte estructurado con
+// el mismo detalle que classifyResponse sin repetir su heurística de
+// clasificación.
+func parseGraphQLErrors(body []byte) (codes, messages []string) {
+	var gqlResp GraphQLResponse
+	if json.Unmarshal(body, &gqlResp) == nil {
+		for _, errObj := range gqlResp.Errors {
+			codes = append(codes, errObj.Extensions.Code)
+			messages = append(messages, errObj.Message)
+		}
+	}
+	return codes, messages
+}
+
+// classifyResponse detecta si la respuesta indica acceso no autorizado
+// utilizando múltiples heurísticas y devuelve, además de la clasificación,
+// cuál de ellas disparó (o "" si ninguna) y los códigos/mensajes de error
+// GraphQL presentes, para que el reporte estructurado explique el porqué
+// sin que quien lo lea tenga que repetir la petición.
+func classifyResponse(resp *http.Response) (classification, heuristic string, errorCodes, errorMessages []string) {
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return report.Denied, "http_status", nil, nil
+	}
+
+	responseBody, _ := io.ReadAll(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewBuffer(responseBody))
+
+	var gqlResp GraphQLResponse
+	if json.Unmarshal(responseBody, &gqlResp) == nil {
+		for _, errObj := range gqlResp.Errors {
+			errorCodes = append(errorCodes, errObj.Extensions.Code)
+			errorMessages = append(errorMessages, errObj.Message)
+		}
+
 		if len(gqlResp.Errors) > 0 {
 			for _, errObj := range gqlResp.Errors {
 				code := strings.ToUpper(errObj.Extensions.Code)
 				if code == "UNAUTHENTICATED" || code == "FORBIDDEN" || code == "ACCESS_DENIED" {
-					return true
+					return report.Denied, "graphql_error_code", errorCodes, errorMessages
 				}
 				for _, re := range unauthorizedRegexes {
 					if re.MatchString(errObj.Message) {
-						return true
+						return report.Denied, "message_regex", errorCodes, errorMessages
 					}
 				}
 			}
 
 			if gqlResp.Data == nil {
-				return true
+				return report.Denied, "null_data", errorCodes, errorMessages
 			}
 		}
 	}
@@ -502,19 +895,19 @@ func containsDeniedMessage(resp *http.Response) bool {
 	lowerBody := strings.ToLower(string(responseBody))
 	for _, keyword := range []string{"unauthorized", "forbidden", "access denied", "restricted"} {
 		if strings.Contains(lowerBody, keyword) {
-			return true
+			return report.Denied, "body_keyword", errorCodes, errorMessages
 		}
 	}
 
 	if resp.StatusCode >= 500 {
-		return false
+		return report.ErrorStatus, "http_5xx", errorCodes, errorMessages
 	}
 
 	if resp.StatusCode == 400 {
-		return false
+		return report.Inconclusive, "http_400", errorCodes, errorMessages
 	}
 
-	return false
+	return report.Allowed, "", errorCodes, errorMessages
 }
 
 // sendRequest envía una petición HTTP, opcionalmente usando proxy
@@ -620,4 +1013,4 @@ func parseRequestFile(filePath string, useSSL bool) (string, map[string]string,
 	}
 
 	return endpointPath, headers, body.String(), nil
-}
\ No newline at end of file
+}